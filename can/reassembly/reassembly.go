@@ -0,0 +1,235 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package reassembly implements NMEA2000 fast-packet reassembly, shared by
+// any CAN transport adapter (CANUSB, SocketCAN, Actisense NGT-1, YDWG-02,
+// ...) that hands it raw frames. A transport only has to turn its own wire
+// format into a Frame and call Reassembler.Feed; this package owns the
+// group/sequence bookkeeping, the partial-message timeout and the
+// concurrency-safety that used to live, unguarded, in canusb.
+package reassembly
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/timmathews/argo/can"
+	"github.com/timmathews/argo/metrics"
+	"github.com/timmathews/argo/nmea2k"
+)
+
+// DefaultTimeout is how long a partial fast-packet message may go without a
+// continuation frame before the sweeper evicts it, per the ~250ms guidance
+// in the NMEA2000 spec.
+const DefaultTimeout = 250 * time.Millisecond
+
+var (
+	// ErrPartial is returned by Feed when frame was accepted into an
+	// in-progress reassembly but the message isn't complete yet.
+	ErrPartial = errors.New("reassembly: message partially received")
+
+	// ErrOutOfSequence is returned when a continuation frame's sequence
+	// number doesn't follow the last frame accepted for its group.
+	ErrOutOfSequence = errors.New("reassembly: frame out of sequence")
+
+	// ErrTimeout identifies a partial message that stalled for longer than
+	// the configured timeout. No function in this package returns it: the
+	// sweeper records each eviction only by incrementing
+	// metrics.FastPacketTimeouts.
+	ErrTimeout = errors.New("reassembly: partial message timed out")
+)
+
+// Frame is the subset of a raw CAN frame a transport adapter needs to
+// provide for fast-packet reassembly. Fields mirror can.RawMessage except
+// Data, which is the frame's own payload (up to 8 bytes) rather than the
+// reassembled PGN payload.
+type Frame struct {
+	Priority    uint8
+	Pgn         uint32
+	Source      uint8
+	Destination uint8
+	Data        []byte
+}
+
+type partial struct {
+	frame    Frame
+	length   int
+	seq      uint8
+	lastSeen time.Time
+}
+
+// Reassembler reassembles NMEA2000 fast-packet frames into complete
+// can.RawMessage values. It is safe for concurrent use: Feed may be called
+// from a transport's read loop while the background sweeper evicts stale
+// entries.
+type Reassembler struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	parts   map[uint32]partial
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New returns a Reassembler that evicts partial messages which haven't
+// seen a continuation frame within timeout. Call Start to begin the
+// background sweeper.
+func New(timeout time.Duration) *Reassembler {
+	return &Reassembler{
+		timeout: timeout,
+		parts:   make(map[uint32]partial),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start launches the background sweeper goroutine. It is idempotent: only
+// the first call has any effect.
+func (r *Reassembler) Start() {
+	r.once.Do(func() {
+		go r.sweep()
+	})
+}
+
+// Close stops the background sweeper. It does not clear any in-flight
+// partial messages.
+func (r *Reassembler) Close() {
+	close(r.stop)
+}
+
+func (r *Reassembler) sweep() {
+	ticker := time.NewTicker(r.timeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			for uid, p := range r.parts {
+				if now.Sub(p.lastSeen) < r.timeout {
+					continue
+				}
+				delete(r.parts, uid)
+				metrics.FastPacketTimeouts.Inc()
+				metrics.PartialMessages.Set(float64(len(r.parts)))
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// groupID returns the PGN, source address and fast-packet group ID packed
+// into a single key unique to one reassembly in progress.
+func groupID(grp uint8, pgn uint32, source uint8) uint32 {
+	return uint32(grp)<<28 | pgn<<8 | uint32(source)
+}
+
+// Feed processes one raw CAN frame. Frames for PGNs that aren't
+// fast-packet encoded are returned as a complete can.RawMessage
+// immediately. Otherwise Feed returns ErrPartial while a reassembly is in
+// progress, ErrOutOfSequence for a continuation frame that doesn't match
+// the expected sequence number, and a complete can.RawMessage (nil error)
+// once the last frame of a group arrives.
+func (r *Reassembler) Feed(f Frame) (*can.RawMessage, error) {
+	if !isFastPacket(f.Pgn) {
+		return toRawMessage(f, uint8(len(f.Data)), f.Data), nil
+	}
+
+	if len(f.Data) == 0 {
+		return nil, errors.New("reassembly: empty frame")
+	}
+
+	seq := f.Data[0] & 0x1F
+	grp := (f.Data[0] & 0x70) >> 5
+	uid := groupID(grp, f.Pgn, f.Source)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if seq == 0 {
+		if _, exists := r.parts[uid]; exists {
+			metrics.FastPacketDuplicateFirstFrames.Inc() // clobbers the old scraps
+		}
+		metrics.FastPacketFirstFrames.Inc()
+
+		length := f.Data[1]
+		data := append([]byte{}, f.Data[2:]...)
+
+		if len(data) >= int(length) {
+			delete(r.parts, uid)
+			metrics.PartialMessages.Set(float64(len(r.parts)))
+			metrics.FastPacketCompleted.Inc()
+			return toRawMessage(f, length, data), nil
+		}
+
+		partialFrame := f
+		partialFrame.Data = data
+		r.parts[uid] = partial{frame: partialFrame, length: int(length), seq: seq, lastSeen: time.Now()}
+		metrics.PartialMessages.Set(float64(len(r.parts)))
+		return nil, ErrPartial
+	}
+
+	p, ok := r.parts[uid]
+	if !ok || p.seq+1 != seq {
+		metrics.FastPacketOutOfSequence.Inc()
+		return nil, ErrOutOfSequence
+	}
+	metrics.FastPacketContinuations.Inc()
+
+	p.frame.Data = append(p.frame.Data, f.Data[1:]...)
+	p.seq = seq
+	p.lastSeen = time.Now()
+
+	if len(p.frame.Data) >= p.length {
+		delete(r.parts, uid)
+		metrics.PartialMessages.Set(float64(len(r.parts)))
+		metrics.FastPacketCompleted.Inc()
+		return toRawMessage(p.frame, uint8(p.length), p.frame.Data), nil
+	}
+
+	r.parts[uid] = p
+	metrics.PartialMessages.Set(float64(len(r.parts)))
+	return nil, ErrPartial
+}
+
+func toRawMessage(f Frame, length uint8, data []byte) *can.RawMessage {
+	return &can.RawMessage{
+		Timestamp:   time.Now(),
+		Priority:    f.Priority,
+		Pgn:         f.Pgn,
+		Source:      f.Source,
+		Destination: f.Destination,
+		Length:      length,
+		Data:        data,
+	}
+}
+
+// isFastPacket reports whether pgn is carried as an NMEA2000 fast packet,
+// i.e. its defined size exceeds the 8 bytes a single CAN frame can carry.
+// PGNs argo doesn't have a definition for are assumed single-frame.
+func isFastPacket(pgn uint32) bool {
+	for _, def := range nmea2k.PgnList {
+		if def.Pgn == pgn {
+			return def.Size > 8
+		}
+	}
+	return false
+}
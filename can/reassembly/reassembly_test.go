@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package reassembly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timmathews/argo/metrics"
+)
+
+// rudderPgn (127245, "Rudder") is single-frame: Size is 8, so it never goes
+// through fast-packet reassembly.
+const rudderPgn = 127245
+
+// positionPgn (129029, "GNSS Position Data") is fast-packet: Size is 51,
+// spanning 8 frames (6 bytes of payload in the first frame, 7 in each
+// continuation).
+const positionPgn = 129029
+
+func TestFeedSingleFrame(t *testing.T) {
+	r := New(DefaultTimeout)
+
+	f := Frame{Priority: 2, Pgn: rudderPgn, Source: 1, Destination: 255, Data: []byte{0, 1, 2, 3, 4, 5, 6, 7}}
+	msg, err := r.Feed(f)
+	if err != nil {
+		t.Fatalf("Feed: unexpected error %v", err)
+	}
+	if msg == nil {
+		t.Fatal("Feed: expected a complete message for a single-frame PGN")
+	}
+	if msg.Length != 8 || string(msg.Data) != string(f.Data) {
+		t.Fatalf("Feed: got length %d data %v, want length 8 data %v", msg.Length, msg.Data, f.Data)
+	}
+}
+
+func TestFeedFastPacketReassembly(t *testing.T) {
+	r := New(DefaultTimeout)
+
+	first := Frame{Priority: 6, Pgn: positionPgn, Source: 1, Destination: 255, Data: []byte{0x00, 13, 1, 2, 3, 4, 5, 6}}
+	msg, err := r.Feed(first)
+	if err != ErrPartial {
+		t.Fatalf("Feed(first): got err %v, want ErrPartial", err)
+	}
+	if msg != nil {
+		t.Fatalf("Feed(first): got message %v, want nil", msg)
+	}
+
+	cont := Frame{Priority: 6, Pgn: positionPgn, Source: 1, Destination: 255, Data: []byte{0x01, 7, 8, 9, 10, 11, 12, 13}}
+	msg, err = r.Feed(cont)
+	if err != nil {
+		t.Fatalf("Feed(continuation): unexpected error %v", err)
+	}
+	if msg == nil {
+		t.Fatal("Feed(continuation): expected a complete message")
+	}
+
+	want := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+	if int(msg.Length) != len(want) || string(msg.Data) != string(want) {
+		t.Fatalf("Feed(continuation): got length %d data %v, want length %d data %v", msg.Length, msg.Data, len(want), want)
+	}
+}
+
+func TestFeedDuplicateFirstFrame(t *testing.T) {
+	r := New(DefaultTimeout)
+	before := testutil.ToFloat64(metrics.FastPacketDuplicateFirstFrames)
+
+	first := Frame{Priority: 6, Pgn: positionPgn, Source: 2, Destination: 255, Data: []byte{0x00, 13, 1, 2, 3, 4, 5, 6}}
+	if _, err := r.Feed(first); err != ErrPartial {
+		t.Fatalf("Feed(first): got err %v, want ErrPartial", err)
+	}
+
+	// A second sequence-0 frame for the same group/PGN/source clobbers the
+	// first reassembly in progress rather than being rejected.
+	second := Frame{Priority: 6, Pgn: positionPgn, Source: 2, Destination: 255, Data: []byte{0x00, 13, 9, 9, 9, 9, 9, 9}}
+	if _, err := r.Feed(second); err != ErrPartial {
+		t.Fatalf("Feed(second): got err %v, want ErrPartial", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.FastPacketDuplicateFirstFrames) - before; got != 1 {
+		t.Fatalf("FastPacketDuplicateFirstFrames increased by %v, want 1", got)
+	}
+}
+
+func TestFeedOutOfSequence(t *testing.T) {
+	r := New(DefaultTimeout)
+	before := testutil.ToFloat64(metrics.FastPacketOutOfSequence)
+
+	first := Frame{Priority: 6, Pgn: positionPgn, Source: 3, Destination: 255, Data: []byte{0x00, 13, 1, 2, 3, 4, 5, 6}}
+	if _, err := r.Feed(first); err != ErrPartial {
+		t.Fatalf("Feed(first): got err %v, want ErrPartial", err)
+	}
+
+	// Sequence 2 skips the expected sequence 1 continuation.
+	skip := Frame{Priority: 6, Pgn: positionPgn, Source: 3, Destination: 255, Data: []byte{0x02, 7, 8, 9, 10, 11, 12, 13}}
+	if _, err := r.Feed(skip); err != ErrOutOfSequence {
+		t.Fatalf("Feed(skip): got err %v, want ErrOutOfSequence", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.FastPacketOutOfSequence) - before; got != 1 {
+		t.Fatalf("FastPacketOutOfSequence increased by %v, want 1", got)
+	}
+}
+
+func TestSweepEvictsStalePartials(t *testing.T) {
+	r := New(10 * time.Millisecond)
+	r.Start()
+	defer r.Close()
+
+	before := testutil.ToFloat64(metrics.FastPacketTimeouts)
+
+	first := Frame{Priority: 6, Pgn: positionPgn, Source: 4, Destination: 255, Data: []byte{0x00, 13, 1, 2, 3, 4, 5, 6}}
+	if _, err := r.Feed(first); err != ErrPartial {
+		t.Fatalf("Feed(first): got err %v, want ErrPartial", err)
+	}
+
+	deadline := time.Now().Add(1 * time.Second)
+	for testutil.ToFloat64(metrics.FastPacketTimeouts)-before < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("sweeper never evicted the stale partial message within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	r.mu.Lock()
+	_, stillPresent := r.parts[groupID(0, positionPgn, 4)]
+	r.mu.Unlock()
+	if stillPresent {
+		t.Fatal("sweeper left the stale partial message in r.parts")
+	}
+}
@@ -23,8 +23,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/timmathews/argo/can"
+	"github.com/timmathews/argo/can/reassembly"
+	"github.com/timmathews/argo/metrics"
 	"io"
-	"time"
+	"strconv"
 )
 
 type CanPort struct {
@@ -33,6 +35,7 @@ type CanPort struct {
 	IsOpen bool
 	rx     chan []byte
 	tx     chan *CanFrame
+	asm    *reassembly.Reassembler
 }
 
 // OpenChannel opens the CAN bus port of the CANUSB adapter for communication.
@@ -62,6 +65,9 @@ func OpenChannel(port io.ReadWriteCloser, address uint8) (p *CanPort, err error)
 		return nil, err
 	}
 
+	asm := reassembly.New(reassembly.DefaultTimeout)
+	asm.Start()
+
 	// TODO: Address negotiation
 	p = &CanPort{
 		p:      port,
@@ -69,6 +75,7 @@ func OpenChannel(port io.ReadWriteCloser, address uint8) (p *CanPort, err error)
 		IsOpen: true,
 		rx:     make(chan []byte),
 		tx:     make(chan *CanFrame),
+		asm:    asm,
 	}
 
 	return p, nil
@@ -84,6 +91,7 @@ func (p *CanPort) CloseChannel() error {
 	fmt.Sprintf(s, "C\r")
 	_, err := p.Write([]byte(s))
 
+	p.asm.Close()
 	close(p.tx)
 	close(p.rx)
 	p.p.Close()
@@ -100,6 +108,7 @@ func (p *CanPort) Read() (frame *can.RawMessage, err error) {
 		for {
 			_, err := p.p.Read(rxbuf)
 			if err != nil {
+				metrics.SerialReadErrors.Inc()
 				return nil, err
 			}
 			for _, b := range rxbuf {
@@ -110,15 +119,9 @@ func (p *CanPort) Read() (frame *can.RawMessage, err error) {
 				} else if b == '\r' && sof == true {
 					rec, err := p.frameReceived(msg)
 					if err == nil {
-						return &can.RawMessage{
-							Timestamp:   time.Now(),
-							Priority:    rec.Priority,
-							Pgn:         rec.Pgn,
-							Source:      rec.Source,
-							Destination: rec.Destination,
-							Length:      rec.Length,
-							Data:        rec.Data,
-						}, nil
+						metrics.PgnReceived.WithLabelValues(strconv.Itoa(int(rec.Pgn))).Inc()
+						metrics.SourceFrames.WithLabelValues(strconv.Itoa(int(rec.Source))).Inc()
+						return rec, nil
 					}
 				} else if sof == true {
 					msg = append(msg, b)
@@ -162,63 +165,23 @@ func (p *CanPort) Write(b []byte) (int, error) {
 	return p.p.Write([]byte(data))
 }
 
-func (p *CanPort) frameReceived(msg []byte) (*CanFrame, error) {
+// frameReceived parses one CANUSB wire-format message and feeds it through
+// the fast-packet reassembler. It returns reassembly.ErrPartial while a
+// multi-frame PGN is still being collected and reassembly.ErrOutOfSequence
+// if a continuation frame doesn't fit the sequence in progress; both
+// replace the old untyped "Partial PGN" sentinel.
+func (p *CanPort) frameReceived(msg []byte) (*can.RawMessage, error) {
 	frame, err := ParseFrame(msg)
 	if err != nil {
 		return nil, err
 	}
-
-	// data[0] bits 7-5: group ID ... i.e. all of these belong together, unless
-	//                   time between packets exceeds an unknown number of ms
-	// data[0] bits 4-0: sequence ... the number of this frame in the sequence
-	//                   of fast packet frames. since we do not know if packets
-	//                   are allowed out of order, assume it is not allowed
-	// data[1]: if sequence is 0 this is the total number of bytes in the fast
-	//          packet set, otherwise it is part of the data
-	//
-	// As a result of the conditions above, fast packets can be up to 223 bytes.
-	// 5 bits for sequence means up to 32 total frames in a fast packet. A frame
-	// can have at most 8 bytes of data, but in fast packet mode the first byte
-	// is always group ID and sequence. Also the first frame of a fast packet can
-	// only have 6 bytes because the second byte is the byte count for the packet
-	//
-	// 223 = 31 * 7 + 6
-	//
-	// Should we bail if we see a byte count > 223?
-
-	if isFastPacket(frame.Pgn) {
-		frame.seq = frame.Data[0] & 0x1F
-		frame.grp = (frame.Data[0] & 0x70) >> 5
-
-		// PGN, source and group ID make a unique identifier for the frame group
-		uid := uint32(frame.grp<<28) + uint32(frame.Pgn<<8) + uint32(frame.Source)
-
-		if frame.seq == 0 { // First in the series
-			delete(partial_messages, uid) // Delete any existing scraps, should probably warn
-			frame.Length = frame.Data[1]
-			frame.Data = frame.Data[2:]
-
-			if len(frame.Data) >= int(frame.Length) {
-				return frame, nil
-			} else {
-				partial_messages[uid] = *frame
-				return nil, errors.New("Partial PGN")
-			}
-		} else {
-			partial, ok := partial_messages[uid]
-			if ok && partial.seq+1 == frame.seq {
-				partial.Data = append(partial.Data, frame.Data[1:]...)
-				partial.seq = frame.seq
-				if len(partial.Data) >= int(partial.Length) {
-					delete(partial_messages, uid)
-					return &partial, nil
-				} else {
-					partial_messages[uid] = partial
-					return nil, errors.New("Partial PGN")
-				}
-			} // If we have a frame out of sequence, should probably warn
-		}
-	}
-
-	return frame, nil
+	metrics.SourceFrames.WithLabelValues(strconv.Itoa(int(frame.Source))).Inc()
+
+	return p.asm.Feed(reassembly.Frame{
+		Priority:    frame.Priority,
+		Pgn:         frame.Pgn,
+		Source:      frame.Source,
+		Destination: frame.Destination,
+		Data:        frame.Data,
+	})
 }
@@ -20,11 +20,15 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/timmathews/argo/metrics"
 	"github.com/timmathews/argo/nmea2k"
+	"github.com/timmathews/argo/rpc"
+	"golang.org/x/crypto/acme/autocert"
 	"net/http"
 	"strconv"
 	"strings"
@@ -41,10 +45,10 @@ type IndexEntry struct {
 	Details         string `json:"@Details"`
 }
 
-type CommandRequest struct {
-	RequestType  string `json:"req_type"`
-	RequestedPgn uint32 `json:"req_pgn"`
-}
+// CommandRequest is an alias for rpc.CommandRequest so the REST control
+// endpoint and the gRPC SendPgn method forward into the same channel type
+// without the two APIs diverging.
+type CommandRequest = rpc.CommandRequest
 
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "<html><head><title>Pyxis API</title></head><body><h1>Pyxis API</h1></body></html>")
@@ -146,20 +150,73 @@ func SendMessageHandler(cmd chan CommandRequest) http.HandlerFunc {
 			if err != nil {
 				fmt.Fprintf(w, "Invalid JSON")
 			}
-			log.Debug("Request Type:", b.RequestType)
-			log.Debug("Requested PGN:", b.RequestedPgn)
+			log.Debug("Request Type:", b.ReqType)
+			log.Debug("Requested PGN:", b.ReqPgn)
 			cmd <- b
 		}
 	}
 }
 
+// instrument wraps a handler so every request against it is counted in
+// metrics.ApiRequests under the given handler name.
+func instrument(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics.ApiRequests.WithLabelValues(name).Inc()
+		h(w, r)
+	}
+}
+
 func ApiServer(cmd chan CommandRequest) {
 	r := mux.NewRouter()
-	r.HandleFunc("/api/v1/", HomeHandler)
-	r.HandleFunc("/api/v1/messages", MessagesIndex)
-	r.HandleFunc("/api/v1/messages/", MessagesIndex)
-	r.HandleFunc("/api/v1/messages/{key}", MessageDetailsHandler)
-	r.HandleFunc("/api/v1/control/send", http.HandlerFunc(SendMessageHandler(cmd)))
+	r.HandleFunc("/api/v1/", instrument("home", HomeHandler))
+	r.HandleFunc("/api/v1/messages", instrument("messages_index", MessagesIndex))
+	r.HandleFunc("/api/v1/messages/", instrument("messages_index", MessagesIndex))
+	r.HandleFunc("/api/v1/messages/{key}", instrument("message_details", MessageDetailsHandler))
+	r.HandleFunc("/api/v1/control/send", instrument("control_send", SendMessageHandler(cmd)))
+	r.Handle("/api/v1/metrics", metrics.Handler())
 	http.Handle("/api/v1/", r)
-	http.ListenAndServe(fmt.Sprint(":", config.WebSockets.Port), nil)
+
+	serve()
+}
+
+// serve starts the HTTP listener for the REST API. Plain HTTP is the
+// default so existing deployments keep working unchanged; setting
+// config.WebSockets.TLS.Enabled switches to HTTPS, either via Let's
+// Encrypt autocert (when Hosts/CacheDir are set) or a manually supplied
+// CertFile/KeyFile pair.
+func serve() {
+	addr := fmt.Sprint(":", config.WebSockets.Port)
+
+	if !config.WebSockets.TLS.Enabled {
+		log.Error(http.ListenAndServe(addr, nil))
+		return
+	}
+
+	tlsAddr := fmt.Sprint(":", config.WebSockets.TLS.Port)
+	srv := &http.Server{Addr: tlsAddr}
+
+	if config.WebSockets.TLS.CacheDir != "" || len(config.WebSockets.TLS.Hosts) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.WebSockets.TLS.Hosts...),
+			Cache:      autocert.DirCache(config.WebSockets.TLS.CacheDir),
+		}
+		srv.TLSConfig = &tls.Config{GetCertificate: m.GetCertificate}
+	}
+
+	if config.WebSockets.TLS.RedirectHTTP {
+		go func() {
+			log.Error(http.ListenAndServe(addr, http.HandlerFunc(redirectToHTTPS)))
+		}()
+	}
+
+	log.Error(srv.ListenAndServeTLS(config.WebSockets.TLS.CertFile, config.WebSockets.TLS.KeyFile))
+}
+
+// redirectToHTTPS sends a permanent redirect from the plain-HTTP listener
+// to the HTTPS one, for use when config.WebSockets.TLS.RedirectHTTP is set.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := strings.Split(r.Host, ":")[0]
+	target := fmt.Sprintf("https://%s:%d%s", host, config.WebSockets.TLS.Port, r.URL.RequestURI())
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
 }
@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/tarm/serial"
+	"github.com/timmathews/argo/canusb"
+	"github.com/timmathews/argo/rpc"
+)
+
+func main() {
+	port, err := serial.OpenPort(&serial.Config{
+		Name: config.Can.Device,
+		Baud: config.Can.Baud,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cp, err := canusb.OpenChannel(port, config.Can.Address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cp.CloseChannel()
+
+	cmd := make(chan CommandRequest)
+	bc := rpc.NewBroadcaster()
+
+	go ApiServer(cmd)
+	go func() {
+		addr := fmt.Sprint(":", config.Rpc.Port)
+		log.Error(rpc.Serve(addr, rpc.NewService(cmd, bc)))
+	}()
+
+	// Drain control requests forwarded by both the REST control endpoint
+	// and the gRPC SendPgn method and push them onto the bus as ISO
+	// Requests (PGN 59904) for the requested PGN.
+	go func() {
+		for req := range cmd {
+			log.Debug("Requesting PGN", req.ReqPgn, "via", req.ReqType)
+			if _, err := cp.Write(isoRequestFrame(req.ReqPgn)); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	// Feed every decoded frame to the gRPC SubscribePgns broadcaster so it
+	// reflects the same live traffic as the REST/WebSocket layer.
+	for {
+		msg, err := cp.Read()
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		bc.Publish(msg)
+	}
+}
+
+// isoRequestFrame builds the byte layout CanPort.Write expects for an ISO
+// Request (PGN 59904, PF 0xEA) asking any node on the bus to transmit pgn:
+// priority, data page, PF, PS/destination, destination, length, then the
+// 3-byte little-endian requested PGN.
+func isoRequestFrame(pgn uint32) []byte {
+	b := make([]byte, 9)
+	b[0] = 6 // ISO Request is sent at priority 6
+	b[1] = 0 // data page
+	b[2] = 0xEA
+	b[3] = 0   // PS, overwritten with the destination address by Write
+	b[4] = 255 // destination: broadcast to all nodes
+	b[5] = 3   // payload length: 3-byte little-endian PGN
+	b[6] = byte(pgn)
+	b[7] = byte(pgn >> 8)
+	b[8] = byte(pgn >> 16)
+	return b
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package metrics holds the Prometheus collectors shared by the CAN bus read
+// path, the fast-packet reassembler and the REST API, so that transport and
+// protocol code only has to reach in and call Inc()/Set() rather than know
+// anything about Prometheus itself.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// PgnReceived counts complete messages (single frames or reassembled
+	// fast packets) per PGN.
+	PgnReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "can",
+		Name:      "pgn_received_total",
+		Help:      "Number of complete messages received, broken down by PGN.",
+	}, []string{"pgn"})
+
+	// SourceFrames counts received frames per CAN source address.
+	SourceFrames = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "can",
+		Name:      "source_frames_total",
+		Help:      "Number of frames received, broken down by source address.",
+	}, []string{"source"})
+
+	// FastPacketFirstFrames counts the first frame of a fast-packet sequence.
+	FastPacketFirstFrames = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "first_frames_total",
+		Help:      "Number of fast-packet first frames (sequence 0) seen.",
+	})
+
+	// FastPacketContinuations counts in-order continuation frames.
+	FastPacketContinuations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "continuations_total",
+		Help:      "Number of in-order fast-packet continuation frames.",
+	})
+
+	// FastPacketOutOfSequence counts continuation frames that didn't match
+	// the expected sequence number for their partial message.
+	FastPacketOutOfSequence = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "out_of_sequence_total",
+		Help:      "Number of fast-packet frames dropped for arriving out of sequence.",
+	})
+
+	// FastPacketDuplicateFirstFrames counts first frames (seq == 0) that
+	// arrived while a partial message for the same group/PGN/source was
+	// already in flight, clobbering it.
+	FastPacketDuplicateFirstFrames = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "duplicate_first_frames_total",
+		Help:      "Number of fast-packet first frames that arrived while a prior reassembly for the same group/PGN/source was still in flight.",
+	})
+
+	// FastPacketCompleted counts fully reassembled fast-packet messages.
+	FastPacketCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "completed_total",
+		Help:      "Number of fast-packet messages successfully reassembled.",
+	})
+
+	// FastPacketTimeouts counts partial messages evicted by the reaper
+	// before they could be completed.
+	FastPacketTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "reassembly_timeouts_total",
+		Help:      "Number of partial fast-packet messages evicted after timing out.",
+	})
+
+	// PartialMessages reports the current number of in-flight fast-packet
+	// reassemblies.
+	PartialMessages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "argo",
+		Subsystem: "fastpacket",
+		Name:      "partial_messages",
+		Help:      "Current number of fast-packet messages awaiting completion.",
+	})
+
+	// SerialReadErrors counts errors returned by the underlying serial read.
+	SerialReadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "can",
+		Name:      "serial_read_errors_total",
+		Help:      "Number of errors encountered reading from the CAN adapter's serial port.",
+	})
+
+	// ApiRequests counts REST API requests by handler.
+	ApiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "argo",
+		Subsystem: "api",
+		Name:      "requests_total",
+		Help:      "Number of REST API requests, broken down by handler.",
+	}, []string{"handler"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		PgnReceived,
+		SourceFrames,
+		FastPacketFirstFrames,
+		FastPacketContinuations,
+		FastPacketOutOfSequence,
+		FastPacketDuplicateFirstFrames,
+		FastPacketCompleted,
+		FastPacketTimeouts,
+		PartialMessages,
+		SerialReadErrors,
+		ApiRequests,
+	)
+}
+
+// Handler returns the http.Handler that serves the registered collectors in
+// Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
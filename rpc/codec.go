@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+)
+
+// jsonCodec implements grpc's encoding.Codec on top of encoding/json rather
+// than real protobuf wire encoding. nmea2k.pb.go and nmea2k_grpc.pb.go in
+// this package are hand-written stand-ins for protoc output, without a
+// protoc/protoc-gen-go-grpc toolchain in the build, so the message types
+// carry protobuf struct tags for documentation but no protobuf reflection
+// data for grpc-go's built-in "proto" codec to use. rpc.Serve selects this
+// codec with grpc.ForceServerCodec, scoped to this package's server only,
+// so it never touches the process-wide "proto" codec other gRPC services
+// rely on. Swap this out for the real codec once genuine protoc output
+// replaces the hand-written pb.go files.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "nmea2k-json"
+}
@@ -0,0 +1,88 @@
+// Package rpc's message types below mirror nmea2k.proto by hand: there is
+// no protoc/protoc-gen-go in this build, so these are not generated code
+// and may be edited directly. Keep them in sync with nmea2k.proto.
+
+package rpc
+
+import "fmt"
+
+type PgnFilter struct {
+	Pgns    []uint32 `protobuf:"varint,1,rep,packed,name=pgns" json:"pgns,omitempty"`
+	Sources []uint32 `protobuf:"varint,2,rep,packed,name=sources" json:"sources,omitempty"`
+}
+
+type DecodedPgn struct {
+	TimestampUnixNano int64  `protobuf:"varint,1,opt,name=timestamp_unix_nano,json=timestampUnixNano" json:"timestamp_unix_nano,omitempty"`
+	Priority          uint32 `protobuf:"varint,2,opt,name=priority" json:"priority,omitempty"`
+	Pgn               uint32 `protobuf:"varint,3,opt,name=pgn" json:"pgn,omitempty"`
+	Source            uint32 `protobuf:"varint,4,opt,name=source" json:"source,omitempty"`
+	Destination       uint32 `protobuf:"varint,5,opt,name=destination" json:"destination,omitempty"`
+	Length            uint32 `protobuf:"varint,6,opt,name=length" json:"length,omitempty"`
+	Data              []byte `protobuf:"bytes,7,opt,name=data" json:"data,omitempty"`
+}
+
+type CommandRequest struct {
+	ReqType string `protobuf:"bytes,1,opt,name=req_type,json=reqType" json:"req_type,omitempty"`
+	ReqPgn  uint32 `protobuf:"varint,2,opt,name=req_pgn,json=reqPgn" json:"req_pgn,omitempty"`
+}
+
+type CommandResponse struct {
+	Accepted bool   `protobuf:"varint,1,opt,name=accepted" json:"accepted,omitempty"`
+	Error    string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+type Empty struct {
+}
+
+type PgnQuery struct {
+	Index uint32 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+}
+
+type PgnDefinition struct {
+	Pgn             uint32 `protobuf:"varint,1,opt,name=pgn" json:"pgn,omitempty"`
+	Description     string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+	Category        string `protobuf:"bytes,3,opt,name=category" json:"category,omitempty"`
+	Size            uint32 `protobuf:"varint,4,opt,name=size" json:"size,omitempty"`
+	RepeatingFields uint32 `protobuf:"varint,5,opt,name=repeating_fields,json=repeatingFields" json:"repeating_fields,omitempty"`
+	IsKnown         bool   `protobuf:"varint,6,opt,name=is_known,json=isKnown" json:"is_known,omitempty"`
+}
+
+type PgnDefinitionList struct {
+	Definitions []*PgnDefinition `protobuf:"bytes,1,rep,name=definitions" json:"definitions,omitempty"`
+}
+
+// Reset, String and ProtoMessage satisfy the legacy github.com/golang/protobuf
+// proto.Message interface for every request/response type on Nmea2kService.
+// jsonCodec, in codec.go, does not use them: it marshals with encoding/json.
+
+func (m *PgnFilter) Reset()         { *m = PgnFilter{} }
+func (m *PgnFilter) String() string { return fmt.Sprintf("%+v", *m) }
+func (*PgnFilter) ProtoMessage()    {}
+
+func (m *DecodedPgn) Reset()         { *m = DecodedPgn{} }
+func (m *DecodedPgn) String() string { return fmt.Sprintf("%+v", *m) }
+func (*DecodedPgn) ProtoMessage()    {}
+
+func (m *CommandRequest) Reset()         { *m = CommandRequest{} }
+func (m *CommandRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*CommandRequest) ProtoMessage()    {}
+
+func (m *CommandResponse) Reset()         { *m = CommandResponse{} }
+func (m *CommandResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*CommandResponse) ProtoMessage()    {}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return fmt.Sprintf("%+v", *m) }
+func (*Empty) ProtoMessage()    {}
+
+func (m *PgnQuery) Reset()         { *m = PgnQuery{} }
+func (m *PgnQuery) String() string { return fmt.Sprintf("%+v", *m) }
+func (*PgnQuery) ProtoMessage()    {}
+
+func (m *PgnDefinition) Reset()         { *m = PgnDefinition{} }
+func (m *PgnDefinition) String() string { return fmt.Sprintf("%+v", *m) }
+func (*PgnDefinition) ProtoMessage()    {}
+
+func (m *PgnDefinitionList) Reset()         { *m = PgnDefinitionList{} }
+func (m *PgnDefinitionList) String() string { return fmt.Sprintf("%+v", *m) }
+func (*PgnDefinitionList) ProtoMessage()    {}
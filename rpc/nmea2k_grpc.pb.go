@@ -0,0 +1,122 @@
+// Package rpc's server dispatch code below mirrors what
+// protoc-gen-go-grpc would emit from nmea2k.proto, hand-written since there
+// is no protoc toolchain in this build. It is not generated code and may be
+// edited directly; keep it in sync with nmea2k.proto.
+
+package rpc
+
+import (
+	context "context"
+
+	grpc "google.golang.org/grpc"
+)
+
+// Nmea2kServiceServer is the server API for Nmea2kService.
+type Nmea2kServiceServer interface {
+	ListPgnDefinitions(context.Context, *Empty) (*PgnDefinitionList, error)
+	GetPgnDefinition(context.Context, *PgnQuery) (*PgnDefinition, error)
+	SubscribePgns(*PgnFilter, Nmea2kService_SubscribePgnsServer) error
+	SendPgn(context.Context, *CommandRequest) (*CommandResponse, error)
+}
+
+// Nmea2kService_SubscribePgnsServer is the server-streaming handle a
+// SubscribePgns implementation sends DecodedPgn messages through.
+type Nmea2kService_SubscribePgnsServer interface {
+	Send(*DecodedPgn) error
+	grpc.ServerStream
+}
+
+type nmea2kServiceSubscribePgnsServer struct {
+	grpc.ServerStream
+}
+
+func (x *nmea2kServiceSubscribePgnsServer) Send(m *DecodedPgn) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RegisterNmea2kServiceServer registers srv with s under the service
+// descriptor below, which mirrors nmea2k.proto.
+func RegisterNmea2kServiceServer(s grpc.ServiceRegistrar, srv Nmea2kServiceServer) {
+	s.RegisterService(&Nmea2kService_ServiceDesc, srv)
+}
+
+func _Nmea2kService_ListPgnDefinitions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Nmea2kServiceServer).ListPgnDefinitions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Nmea2kService/ListPgnDefinitions"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Nmea2kServiceServer).ListPgnDefinitions(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nmea2kService_GetPgnDefinition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PgnQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Nmea2kServiceServer).GetPgnDefinition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Nmea2kService/GetPgnDefinition"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Nmea2kServiceServer).GetPgnDefinition(ctx, req.(*PgnQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nmea2kService_SendPgn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Nmea2kServiceServer).SendPgn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpc.Nmea2kService/SendPgn"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Nmea2kServiceServer).SendPgn(ctx, req.(*CommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nmea2kService_SubscribePgns_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PgnFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(Nmea2kServiceServer).SubscribePgns(m, &nmea2kServiceSubscribePgnsServer{stream})
+}
+
+// Nmea2kService_ServiceDesc is the grpc.ServiceDesc for Nmea2kService.
+var Nmea2kService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rpc.Nmea2kService",
+	HandlerType: (*Nmea2kServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPgnDefinitions",
+			Handler:    _Nmea2kService_ListPgnDefinitions_Handler,
+		},
+		{
+			MethodName: "GetPgnDefinition",
+			Handler:    _Nmea2kService_GetPgnDefinition_Handler,
+		},
+		{
+			MethodName: "SendPgn",
+			Handler:    _Nmea2kService_SendPgn_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribePgns",
+			Handler:       _Nmea2kService_SubscribePgns_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "nmea2k.proto",
+}
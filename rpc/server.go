@@ -0,0 +1,218 @@
+/*
+ * Copyright (C) 2016 Tim Mathews <tim@signalk.org>
+ *
+ * This file is part of Argo.
+ *
+ * Argo is free software: you can redistribute it and/or modify it under the
+ * terms of the GNU General Public License as published by the Free Software
+ * Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * Argo is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+ * FOR A PARTICULAR PURPOSE. See the GNU General Public License for more
+ * details.
+ *
+ * You should have received a copy of the GNU General Public License along with
+ * this program. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package rpc provides a gRPC view onto the same PGN catalogue and live CAN
+// traffic as the REST/WebSocket API, defined in nmea2k.proto. It reuses
+// nmea2k.PgnList for its catalogue so the schema has a single source of
+// truth, and forwards SendPgn calls into the same command channel the REST
+// control endpoint writes to.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/timmathews/argo/can"
+	"github.com/timmathews/argo/nmea2k"
+	"google.golang.org/grpc"
+)
+
+// subscriberBacklog is how many undelivered messages a slow subscriber may
+// accumulate before new messages are dropped for it rather than blocking
+// the broadcaster.
+const subscriberBacklog = 64
+
+// Broadcaster fans decoded CAN traffic out to any number of gRPC
+// SubscribePgns streams (and, in principle, any other consumer that wants
+// a feed of the same messages) with per-client backpressure: a slow
+// subscriber has messages dropped for it instead of stalling the others.
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan *DecodedPgn]*PgnFilter
+}
+
+// NewBroadcaster returns an empty Broadcaster ready to accept subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[chan *DecodedPgn]*PgnFilter)}
+}
+
+// Subscribe registers a new listener matching filter (nil or empty matches
+// everything) and returns the channel it will receive DecodedPgn messages
+// on, plus a cancel function that must be called to unregister it.
+func (b *Broadcaster) Subscribe(filter *PgnFilter) (ch chan *DecodedPgn, cancel func()) {
+	ch = make(chan *DecodedPgn, subscriberBacklog)
+
+	b.mu.Lock()
+	b.subs[ch] = filter
+	b.mu.Unlock()
+
+	cancel = func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+		close(ch)
+	}
+
+	return ch, cancel
+}
+
+// Publish converts msg and fans it out to every subscriber whose filter
+// matches. Subscribers whose backlog is full have this message dropped
+// rather than blocking the sender.
+func (b *Broadcaster) Publish(msg *can.RawMessage) {
+	dp := &DecodedPgn{
+		TimestampUnixNano: msg.Timestamp.UnixNano(),
+		Priority:          uint32(msg.Priority),
+		Pgn:               msg.Pgn,
+		Source:            uint32(msg.Source),
+		Destination:       uint32(msg.Destination),
+		Length:            uint32(msg.Length),
+		Data:              msg.Data,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, filter := range b.subs {
+		if !matches(filter, dp) {
+			continue
+		}
+
+		select {
+		case ch <- dp:
+		default: // subscriber backlog full, drop for it
+		}
+	}
+}
+
+func matches(filter *PgnFilter, dp *DecodedPgn) bool {
+	if filter == nil {
+		return true
+	}
+
+	if len(filter.Pgns) > 0 && !containsU32(filter.Pgns, dp.Pgn) {
+		return false
+	}
+
+	if len(filter.Sources) > 0 && !containsU32(filter.Sources, dp.Source) {
+		return false
+	}
+
+	return true
+}
+
+func containsU32(haystack []uint32, needle uint32) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// Service implements Nmea2kServiceServer.
+type Service struct {
+	cmd chan<- CommandRequest
+	bc  *Broadcaster
+}
+
+// NewService returns a Service that forwards SendPgn calls into cmd (the
+// same channel SendMessageHandler writes to) and serves SubscribePgns from
+// bc.
+func NewService(cmd chan<- CommandRequest, bc *Broadcaster) *Service {
+	return &Service{cmd: cmd, bc: bc}
+}
+
+func (s *Service) ListPgnDefinitions(ctx context.Context, _ *Empty) (*PgnDefinitionList, error) {
+	defs := make([]*PgnDefinition, 0, len(nmea2k.PgnList))
+
+	for _, pgn := range nmea2k.PgnList {
+		defs = append(defs, toPgnDefinition(&pgn))
+	}
+
+	return &PgnDefinitionList{Definitions: defs}, nil
+}
+
+func (s *Service) GetPgnDefinition(ctx context.Context, q *PgnQuery) (*PgnDefinition, error) {
+	if int(q.Index) >= len(nmea2k.PgnList) {
+		return nil, errors.New("rpc: index out of range")
+	}
+
+	return toPgnDefinition(&nmea2k.PgnList[q.Index]), nil
+}
+
+func toPgnDefinition(pgn *nmea2k.Pgn) *PgnDefinition {
+	return &PgnDefinition{
+		Pgn:             pgn.Pgn,
+		Description:     pgn.Description,
+		Category:        pgn.Category,
+		Size:            pgn.Size,
+		RepeatingFields: pgn.RepeatingFields,
+		IsKnown:         pgn.IsKnown,
+	}
+}
+
+func (s *Service) SubscribePgns(filter *PgnFilter, stream Nmea2kService_SubscribePgnsServer) error {
+	ch, cancel := s.bc.Subscribe(filter)
+	defer cancel()
+
+	for {
+		select {
+		case dp, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(dp); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *Service) SendPgn(ctx context.Context, req *CommandRequest) (*CommandResponse, error) {
+	select {
+	case s.cmd <- *req:
+		return &CommandResponse{Accepted: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Serve starts the gRPC listener on addr, serving svc until the listener
+// fails or the process exits. It runs on its own listener/port so it can
+// share the process with ApiServer without the two protocols colliding.
+func Serve(addr string, svc *Service) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("rpc: listen: %w", err)
+	}
+
+	// ForceServerCodec scopes jsonCodec to this server alone, so it never
+	// shadows the real "proto" codec for any other gRPC service in the
+	// process.
+	s := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	RegisterNmea2kServiceServer(s, svc)
+
+	return s.Serve(lis)
+}